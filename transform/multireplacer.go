@@ -0,0 +1,331 @@
+package transform
+
+import (
+	"io"
+
+	"golang.org/x/text/transform"
+)
+
+// mrNode is a node of the trie used by MultiReplacer. After the trie is
+// built, child is turned into a complete Aho-Corasick goto function: for
+// every byte value child[b] is non-nil and points either to a direct
+// extension of the current node or, for bytes with no such extension, to
+// whatever node fail(node).child[b] resolves to.
+type mrNode struct {
+	child   [256]*mrNode
+	fail    *mrNode
+	depth   int
+	ownTerm bool
+	new     []byte
+	idx     int
+}
+
+// MultiReplacer replaces every occurrence of any old pattern in a
+// ReplaceTable with its corresponding new pattern in a single left-to-right
+// scan of the source. Unlike chaining several Replacers, a rule can never
+// match text produced by another rule, because only untouched source bytes
+// are ever fed back into the trie.
+// It implements transform.Transformer.
+type MultiReplacer struct {
+	root    *mrNode
+	history *ReplaceHistory
+
+	// byteToByte, byteTab and byteChanged implement a specialization for
+	// the common case where every rule replaces exactly one byte with
+	// exactly one byte: a 256-entry translation table, mirroring the
+	// byteReplacer specialization in strings.Replacer. Single-byte matches
+	// never straddle a buffer boundary, so this path needs no trie walk and
+	// no boundary handling.
+	byteToByte  bool
+	byteTab     [256]byte
+	byteChanged [256]bool
+}
+
+var _ transform.Transformer = (*MultiReplacer)(nil)
+
+// NewMultiReplacer creates a new MultiReplacer which applies every rule of t.
+// At each position of the source, the longest pattern matching there wins;
+// ties (two rules sharing the same old pattern) are broken by table order,
+// the earlier rule winning. Patterns with an empty old are ignored, since a
+// zero-width match cannot advance a left-to-right scan.
+//
+// NewMultiReplacer does not impose a limit on the length of old patterns.
+// Transform's candidate window for a match in progress can grow as large as
+// the longest old pattern before Transform is forced to flush it, so old
+// patterns must stay shorter than the smallest buffer in the pipeline
+// calling Transform; transform.Reader and transform.Writer use a fixed
+// 4096-byte buffer that never grows, so once that buffer holds nothing but
+// a prefix of a pattern longer than that, Transform returns
+// transform.ErrShortSrc with nSrc == 0 on every call.
+//
+// If history is not nil, MultiReplacer records histories of replacing.
+func NewMultiReplacer(t ReplaceTable, history *ReplaceHistory) *MultiReplacer {
+	r := &MultiReplacer{history: history}
+
+	if isByteToByteTable(t) {
+		for b := range r.byteTab {
+			r.byteTab[b] = byte(b)
+		}
+		for i := 0; i < t.Len(); i++ {
+			old, new := t.At(i)
+			b := old[0]
+			if !r.byteChanged[b] {
+				r.byteChanged[b] = true
+				r.byteTab[b] = new[0]
+			}
+		}
+		r.byteToByte = true
+		return r
+	}
+
+	r.root = buildMRTrie(t)
+	return r
+}
+
+// isByteToByteTable reports whether every rule of t replaces exactly one
+// byte with exactly one byte, e.g. a ReplaceRuneTable restricted to ASCII or
+// byte-level scrubbing.
+func isByteToByteTable(t ReplaceTable) bool {
+	if t.Len() == 0 {
+		return false
+	}
+	for i := 0; i < t.Len(); i++ {
+		old, new := t.At(i)
+		if len(old) != 1 || len(new) != 1 {
+			return false
+		}
+	}
+	return true
+}
+
+func buildMRTrie(t ReplaceTable) *mrNode {
+	root := &mrNode{}
+
+	for i := 0; i < t.Len(); i++ {
+		old, new := t.At(i)
+		if len(old) == 0 {
+			continue
+		}
+
+		cur := root
+		for _, b := range old {
+			next := cur.child[b]
+			if next == nil {
+				next = &mrNode{depth: cur.depth + 1}
+				cur.child[b] = next
+			}
+			cur = next
+		}
+		if !cur.ownTerm {
+			cur.ownTerm = true
+			cur.new = new
+			cur.idx = i
+		}
+	}
+
+	// Complete the trie into an Aho-Corasick automaton: compute fail links
+	// by BFS and fill in the missing transitions so that child[b] becomes a
+	// total function (a "goto" table) for every node.
+	queue := make([]*mrNode, 0)
+	root.fail = root
+	for b := 0; b < 256; b++ {
+		if root.child[b] == nil {
+			root.child[b] = root
+			continue
+		}
+		root.child[b].fail = root
+		queue = append(queue, root.child[b])
+	}
+	for len(queue) > 0 {
+		u := queue[0]
+		queue = queue[1:]
+		for b := 0; b < 256; b++ {
+			v := u.child[b]
+			if v == nil {
+				u.child[b] = u.fail.child[b]
+				continue
+			}
+			v.fail = u.fail.child[b]
+			queue = append(queue, v)
+		}
+	}
+
+	return root
+}
+
+// Reset implements transform.Transformer.Reset.
+func (r *MultiReplacer) Reset() {}
+
+// WriteString applies every rule to s and writes the result to w, analogous
+// to strings.Replacer.WriteString.
+// WriteString is safe for concurrent use by multiple goroutines as long as
+// r.history is nil.
+func (r *MultiReplacer) WriteString(w io.Writer, s string) (n int, err error) {
+	cw := &countingWriter{w: w}
+	tw := r.NewWriter(cw)
+	_, err = tw.Write([]byte(s))
+	if cerr := tw.Close(); err == nil {
+		err = cerr
+	}
+	return cw.n, err
+}
+
+// Replace returns a copy of s with every rule applied, analogous to
+// strings.Replacer.Replace.
+// Replace is safe for concurrent use by multiple goroutines as long as
+// r.history is nil.
+func (r *MultiReplacer) Replace(s []byte) []byte {
+	out, _, _ := transform.Bytes(r, s)
+	return out
+}
+
+// NewWriter wraps w so that everything written to it has every rule applied
+// before reaching w. The returned Writer buffers boundary bytes between
+// Write calls, and Close flushes the tail as if atEOF were true.
+// NewWriter is safe for concurrent use by multiple goroutines as long as
+// r.history is nil.
+func (r *MultiReplacer) NewWriter(w io.Writer) io.WriteCloser {
+	return transform.NewWriter(w, r)
+}
+
+// Transform implements transform.Transformer.Transform.
+// Transform walks src byte by byte over the automaton, always restarting
+// the candidate window at matchStart with cur at the trie root. While the
+// automaton stays at depth pos-matchStart+1 the window is still a prefix of
+// some pattern, so the longest ownTerm seen along the way is remembered as
+// the best match starting at matchStart. As soon as a transition would drop
+// below that depth, the window can no longer grow into a longer match, so
+// the best match found so far (or, failing that, a single raw byte) is
+// flushed and scanning resumes from matchStart with cur back at the root —
+// the same position Transform can safely resume from on a later call.
+//
+// The fail links computed in buildMRTrie are only consulted here to decide
+// whether a transition keeps the candidate window alive (next.depth ==
+// aliveLen) or not; once the window dies, Transform restarts the trie walk
+// at the root from matchStart instead of continuing from the fail-derived
+// automaton state. This is simpler to reason about than threading the
+// longest-pattern-ending-here bookkeeping through the automaton's output
+// links, at the cost of O(n*maxPatternLen) worst case rather than the O(n)
+// a fully streaming Aho-Corasick scan would give; maxPatternLen is small in
+// the tables this package is built for, so the gap is not expected to
+// matter in practice.
+func (r *MultiReplacer) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	if r.byteToByte {
+		return r.transformByteToByte(dst, src)
+	}
+
+	matchStart := 0
+	pos := 0
+	cur := r.root
+	bestLen := 0
+	var bestNew []byte
+
+	commitRaw := func(end int) bool {
+		n := end - matchStart
+		if n == 0 {
+			return true
+		}
+		if len(dst[nDst:]) < n {
+			err = transform.ErrShortDst
+			return false
+		}
+		nDst += copy(dst[nDst:], src[matchStart:end])
+		nSrc = end
+		matchStart = end
+		return true
+	}
+
+	commitMatch := func() bool {
+		if len(dst[nDst:]) < len(bestNew) {
+			err = transform.ErrShortDst
+			return false
+		}
+		r.history.add(nSrc, nSrc+bestLen, nDst, nDst+len(bestNew))
+		nDst += copy(dst[nDst:], bestNew)
+		nSrc += bestLen
+		matchStart = nSrc
+		return true
+	}
+
+	for {
+		for pos < len(src) {
+			b := src[pos]
+			next := cur.child[b]
+			aliveLen := pos - matchStart + 1
+
+			if next.depth == aliveLen {
+				cur = next
+				if cur.ownTerm {
+					bestLen = aliveLen
+					bestNew = cur.new
+				}
+				pos++
+				continue
+			}
+
+			if bestLen > 0 {
+				if !commitMatch() {
+					return
+				}
+			} else if !commitRaw(matchStart + 1) {
+				return
+			}
+			pos = matchStart
+			cur = r.root
+			bestLen = 0
+			bestNew = nil
+		}
+
+		if matchStart == len(src) {
+			return
+		}
+		if !atEOF {
+			err = transform.ErrShortSrc
+			return
+		}
+
+		// The window is still alive with nothing left to extend it: resolve
+		// it the same way a dead end does, then loop back to rescan the
+		// remainder instead of dumping it raw, so a rule matching inside the
+		// tail of a committed match (e.g. "ab" committing out of an "abcd"
+		// window, leaving "c" to still match its own rule) is not missed.
+		if bestLen > 0 {
+			if !commitMatch() {
+				return
+			}
+			pos = matchStart
+			cur = r.root
+			bestLen = 0
+			bestNew = nil
+			continue
+		}
+		if !commitRaw(len(src)) {
+			return
+		}
+	}
+}
+
+// transformByteToByte is the fast path used when every rule replaces
+// exactly one byte with exactly one byte. It never returns
+// transform.ErrShortSrc, since a single-byte match can never straddle a
+// buffer boundary.
+func (r *MultiReplacer) transformByteToByte(dst, src []byte) (nDst, nSrc int, err error) {
+	n := len(src)
+	if len(dst) < n {
+		n = len(dst)
+	}
+
+	for i := 0; i < n; i++ {
+		b := src[i]
+		if r.byteChanged[b] {
+			r.history.add(i, i+1, i, i+1)
+		}
+		dst[i] = r.byteTab[b]
+	}
+
+	nDst, nSrc = n, n
+	if n < len(src) {
+		err = transform.ErrShortDst
+	}
+	return
+}
@@ -0,0 +1,177 @@
+package transform
+
+import (
+	"regexp"
+
+	"golang.org/x/text/transform"
+)
+
+// defaultMaxMatchLen is the default value of RegexpReplacer.MaxMatchLen.
+const defaultMaxMatchLen = 1024
+
+// RegexpReplacer replaces every match of a regular expression with a
+// replacement computed either by regexp.Regexp.Expand (for a $1-style
+// template) or by a caller-supplied function.
+// It implements transform.Transformer.
+type RegexpReplacer struct {
+	re       *regexp.Regexp
+	repl     []byte
+	replFunc func(match []byte) []byte
+	history  *ReplaceHistory
+
+	// MaxMatchLen bounds how close to the end of the current src buffer a
+	// tentative match may end before Transform refuses to commit it and
+	// returns transform.ErrShortSrc instead, since re could still extend
+	// the match further into src that has not arrived yet. It defaults to
+	// defaultMaxMatchLen and may be changed before the first Transform call.
+	//
+	// It also bounds how much of a non-matching tail Transform holds back
+	// when re finds no match at all: only the last MaxMatchLen-1 bytes are
+	// kept, since any later match could still start there, and everything
+	// before that is flushed so a long non-matching run still makes forward
+	// progress instead of accumulating in full until atEOF. A pattern that
+	// genuinely requires more than MaxMatchLen bytes of trailing context to
+	// confirm a match (e.g. "a.*b" with a gap longer than MaxMatchLen) will
+	// have that earlier context flushed away and the match missed; raise
+	// MaxMatchLen to cover the longest gap such patterns need.
+	MaxMatchLen int
+}
+
+var _ transform.Transformer = (*RegexpReplacer)(nil)
+
+// NewRegexpReplacer creates a new RegexpReplacer which replaces every match
+// of re with repl, expanded as by regexp.Regexp.Expand (so repl may contain
+// $1-style references to re's submatches).
+//
+// If history is not nil, RegexpReplacer records histories of replacing.
+func NewRegexpReplacer(re *regexp.Regexp, repl []byte, history *ReplaceHistory) *RegexpReplacer {
+	return &RegexpReplacer{
+		re:          re,
+		repl:        repl,
+		history:     history,
+		MaxMatchLen: defaultMaxMatchLen,
+	}
+}
+
+// NewRegexpReplacerFunc creates a new RegexpReplacer which replaces every
+// match of re with the result of calling f with the matched bytes.
+//
+// If history is not nil, RegexpReplacer records histories of replacing.
+func NewRegexpReplacerFunc(re *regexp.Regexp, f func(match []byte) []byte, history *ReplaceHistory) *RegexpReplacer {
+	return &RegexpReplacer{
+		re:          re,
+		replFunc:    f,
+		history:     history,
+		MaxMatchLen: defaultMaxMatchLen,
+	}
+}
+
+// Reset implements transform.Transformer.Reset.
+func (r *RegexpReplacer) Reset() {}
+
+// Transform implements transform.Transformer.Transform.
+//
+// Because a regexp match can extend arbitrarily far, Transform refuses to
+// commit a tentative match whose end is within r.MaxMatchLen of the end of
+// the current src when atEOF is false, since more src might let re match
+// further and change where the match ends. It returns transform.ErrShortSrc
+// in that case so the caller supplies more input.
+//
+// Whenever dst is too small to hold the next piece of output, Transform
+// returns transform.ErrShortDst unconditionally, even if nDst > 0 from
+// earlier matches processed in this call: a nil error requires nSrc ==
+// len(src), which does not hold here.
+func (r *RegexpReplacer) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	for {
+		tail := src[nSrc:]
+		loc := r.re.FindSubmatchIndex(tail)
+
+		if loc == nil {
+			if !atEOF {
+				// A match could still start within the last MaxMatchLen-1
+				// bytes of tail and complete once more src arrives, the same
+				// bound Transform already relies on below for a match it did
+				// find. Flush everything strictly before that bounded
+				// suffix — a long non-matching run must still make forward
+				// progress instead of holding back all of tail until EOF.
+				keep := r.MaxMatchLen - 1
+				if keep < 0 {
+					keep = 0
+				}
+				n := len(tail) - keep
+				if n <= 0 {
+					err = transform.ErrShortSrc
+					return
+				}
+				if len(dst[nDst:]) < n {
+					err = transform.ErrShortDst
+					return
+				}
+				nDst += copy(dst[nDst:], tail[:n])
+				nSrc += n
+				err = transform.ErrShortSrc
+				return
+			}
+
+			n := len(tail)
+			if len(dst[nDst:]) < n {
+				err = transform.ErrShortDst
+				return
+			}
+			nDst += copy(dst[nDst:], tail)
+			nSrc += n
+			return
+		}
+
+		start, end := loc[0], loc[1]
+		if !atEOF && end > len(tail)-r.MaxMatchLen {
+			// The match might not be final: re could extend it given more
+			// src. Flush only the untouched bytes strictly before it and
+			// ask for more.
+			if len(dst[nDst:]) < start {
+				err = transform.ErrShortDst
+				return
+			}
+			nDst += copy(dst[nDst:], tail[:start])
+			nSrc += start
+			err = transform.ErrShortSrc
+			return
+		}
+
+		var replacement []byte
+		if r.replFunc != nil {
+			replacement = r.replFunc(tail[start:end])
+		} else {
+			replacement = r.re.Expand(nil, r.repl, tail, loc)
+		}
+
+		// A zero-width match (e.g. "a*", "\b", "^") never advances src on its
+		// own; without also committing the next byte, the next iteration
+		// would find the same empty match at the same position forever. So
+		// that a match is never committed without the byte that forces
+		// progress past it, check room for both before writing either.
+		extra := 0
+		if start == end && nSrc+end < len(src) {
+			extra = 1
+		}
+
+		if len(dst[nDst:]) < start+len(replacement)+extra {
+			err = transform.ErrShortDst
+			return
+		}
+		nDst += copy(dst[nDst:], tail[:start])
+		r.history.add(nSrc+start, nSrc+end, nDst, nDst+len(replacement))
+		nDst += copy(dst[nDst:], replacement)
+		nSrc += end
+
+		if extra == 1 {
+			dst[nDst] = src[nSrc]
+			nDst++
+			nSrc++
+		} else if start == end {
+			// end of src reached exactly on a zero-width match; nothing
+			// left to force progress past, so stop here.
+			return
+		}
+	}
+}
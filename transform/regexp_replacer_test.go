@@ -0,0 +1,78 @@
+package transform
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"testing"
+
+	"golang.org/x/text/transform"
+)
+
+func TestRegexpReplacerZeroWidthMatch(t *testing.T) {
+	r := NewRegexpReplacer(regexp.MustCompile("a*"), []byte("X"), nil)
+
+	got, _, err := transform.Bytes(r, []byte("bbb"))
+	if err != nil {
+		t.Fatalf("transform.Bytes returned error: %v", err)
+	}
+
+	want := "XbXbXbX"
+	if string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRegexpReplacerNoMatchKeepsGapWithinMaxMatchLen(t *testing.T) {
+	re := regexp.MustCompile("a.*?b")
+	r := NewRegexpReplacer(re, []byte("X"), nil)
+	// The gap must fit within MaxMatchLen-1: Transform now bounds how much
+	// of a non-matching tail it holds back by the same MaxMatchLen used to
+	// gate committing an already-found match, so a gap longer than that
+	// would have its leading "a" flushed away before "b" arrives.
+	r.MaxMatchLen = 256
+
+	input := "a" + string(bytes.Repeat([]byte("z"), 200)) + "b" + "TAIL"
+
+	pr, pw := io.Pipe()
+	go func() {
+		defer pw.Close()
+		for i := 0; i < len(input); i += 20 {
+			end := i + 20
+			if end > len(input) {
+				end = len(input)
+			}
+			pw.Write([]byte(input[i:end]))
+		}
+	}()
+
+	tr := transform.NewReader(pr, r)
+	out, err := io.ReadAll(tr)
+	if err != nil {
+		t.Fatalf("ReadAll returned error: %v", err)
+	}
+
+	want := "XTAIL"
+	if string(out) != want {
+		t.Errorf("got %q, want %q", out, want)
+	}
+}
+
+func TestRegexpReplacerNoMatchMakesForwardProgress(t *testing.T) {
+	// Streaming a long run that never matches must not accumulate in full
+	// in transform.Reader's fixed 4096-byte buffer: if it did, once the
+	// buffer filled without re finding a match, Reader would give up with
+	// the transformer's ErrShortSrc instead of growing the buffer further.
+	re := regexp.MustCompile("abc")
+	r := NewRegexpReplacer(re, []byte("X"), nil)
+
+	in := bytes.Repeat([]byte("z"), 10000)
+	tr := transform.NewReader(bytes.NewReader(in), r)
+	out, err := io.ReadAll(tr)
+	if err != nil {
+		t.Fatalf("ReadAll returned error: %v", err)
+	}
+	if !bytes.Equal(out, in) {
+		t.Errorf("got %d bytes, want %d bytes unchanged", len(out), len(in))
+	}
+}
@@ -2,6 +2,7 @@ package transform
 
 import (
 	"bytes"
+	"io"
 	"unicode/utf8"
 
 	"golang.org/x/text/transform"
@@ -52,13 +53,45 @@ func (h *ReplaceHistory) At(index int) (src0, src1, dst0, dst1 int) {
 	return h.src0[index], h.src1[index], h.dst0[index], h.dst1[index]
 }
 
+// countingWriter wraps an io.Writer to track the number of bytes actually
+// written to it. transform.Writer.Write reports bytes consumed from its
+// input, per the io.Writer contract, not bytes produced; WriteString needs
+// the latter to match strings.Replacer.WriteString's n.
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += n
+	return n, err
+}
+
 // Replacer replaces a part of byte data which matches given pattern to other pattern.
 // It implements transform.Transformer.
 type Replacer struct {
 	old, new []byte
 	history  *ReplaceHistory
+
+	// overlap is the KMP failure function of old, used to find the longest
+	// suffix of a src buffer that is also a prefix of old when old may
+	// straddle the boundary between this Transform call and the next.
+	overlap []int
+
+	// badChar is the Boyer-Moore-Horspool bad-character shift table, only
+	// populated (and used) when len(old) is long enough for the table to
+	// pay for itself.
+	badChar [256]int
+	useBMH  bool
 }
 
+// boyerMooreHorspoolMinLen is the shortest old pattern for which the
+// Boyer-Moore-Horspool bad-character table is built; below it, bytes.Index's
+// general-purpose search already performs well and the table would not earn
+// back its setup cost.
+const boyerMooreHorspoolMinLen = 4
+
 var _ transform.Transformer = (*Replacer)(nil)
 
 // NewReplacer creates a new Replacer which replaces old to new.
@@ -67,11 +100,86 @@ var _ transform.Transformer = (*Replacer)(nil)
 //
 // If history is not nil, Replacer records histories of replacing.
 func NewReplacer(old, new []byte, history *ReplaceHistory) *Replacer {
-	return &Replacer{
+	r := &Replacer{
 		new:     new,
 		old:     old,
 		history: history,
 	}
+
+	if len(old) > 0 {
+		r.overlap = kmpFailure(old)
+	}
+
+	if len(old) >= boyerMooreHorspoolMinLen {
+		for c := range r.badChar {
+			r.badChar[c] = len(old)
+		}
+		for i := 0; i < len(old)-1; i++ {
+			r.badChar[old[i]] = len(old) - 1 - i
+		}
+		r.useBMH = true
+	}
+
+	return r
+}
+
+// kmpFailure computes the KMP failure function of pattern: fail[i] is the
+// length of the longest proper prefix of pattern[:i+1] that is also a
+// suffix of pattern[:i+1].
+func kmpFailure(pattern []byte) []int {
+	fail := make([]int, len(pattern))
+	k := 0
+	for i := 1; i < len(pattern); i++ {
+		for k > 0 && pattern[k] != pattern[i] {
+			k = fail[k-1]
+		}
+		if pattern[k] == pattern[i] {
+			k++
+		}
+		fail[i] = k
+	}
+	return fail
+}
+
+// kmpOverlap returns the length of the longest suffix of text that is also
+// a prefix of pattern, using pattern's precomputed KMP failure function.
+func kmpOverlap(text, pattern []byte, fail []int) int {
+	state := 0
+	for _, c := range text {
+		for state > 0 && pattern[state] != c {
+			state = fail[state-1]
+		}
+		if pattern[state] == c {
+			state++
+		}
+		if state == len(pattern) {
+			state = fail[state-1]
+		}
+	}
+	return state
+}
+
+// index returns the position of the first occurrence of r.old in s, or -1
+// if it is not present. For patterns long enough to benefit, it uses
+// Boyer-Moore-Horspool instead of bytes.Index's general-purpose search.
+func (r *Replacer) index(s []byte) int {
+	if !r.useBMH {
+		return bytes.Index(s, r.old)
+	}
+
+	n := len(r.old)
+	last := n - 1
+	for i := 0; i <= len(s)-n; {
+		j := last
+		for j >= 0 && s[i+j] == r.old[j] {
+			j--
+		}
+		if j < 0 {
+			return i
+		}
+		i += r.badChar[s[i+last]]
+	}
+	return -1
 }
 
 // Reset implements transform.Transformer.Reset.
@@ -82,16 +190,33 @@ func (r *Replacer) Reset() {}
 //
 // Because the transforming is taken by part of source data with transform.Reader
 // the Replacer is carefull for boundary of current src buffer and next one.
-// When end of src matches for part of old and atEOF is false
-// the Replacer stops to transform and remain len(src) % len(old) bytes for next transforming.
+// When the tail of src matches a (possibly partial) prefix of old and atEOF
+// is false, the Replacer stops to transform and remains the longest such
+// suffix for next transforming.
 // If Replacer remained boundary bytes, nSrc will be less than len(src)
 // and returns transform.ErrShortSrc.
+//
+// Whenever dst is too small to hold the next piece of output, Transform
+// returns transform.ErrShortDst unconditionally, even if nDst > 0 from
+// earlier matches processed in this call: a nil error requires nSrc ==
+// len(src), which does not hold here.
 func (r *Replacer) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
 
 	if len(src) < len(r.old) {
 		if !atEOF {
 			err = transform.ErrShortSrc
+			return
 		}
+
+		// The remaining src can never contain old, so it passes through
+		// untouched, the same as the "not found" case below.
+		if len(dst) < len(src) {
+			err = transform.ErrShortDst
+			return
+		}
+		n := copy(dst, src)
+		nDst += n
+		nSrc += n
 		return
 	}
 
@@ -103,22 +228,23 @@ func (r *Replacer) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err e
 	}
 
 	for {
-		i := bytes.Index(src[nSrc:], r.old)
+		i := r.index(src[nSrc:])
 
 		if i == -1 { // not found
 			n := len(src[nSrc:])
 
-			boundary := len(src[nSrc:]) % len(r.old)
-			if !atEOF && boundary != 0 && bytes.HasPrefix(r.old, src[len(src)-boundary:]) {
-				// exclude boundary bytes because they may match r.old with next several bytes
-				n -= boundary
-				err = transform.ErrShortDst
+			if !atEOF {
+				// exclude the longest suffix of the tail that is also a
+				// prefix of r.old, because it may match r.old with next
+				// several bytes
+				if overlap := kmpOverlap(src[nSrc:], r.old, r.overlap); overlap > 0 {
+					n -= overlap
+					err = transform.ErrShortSrc
+				}
 			}
 
 			if len(dst[nDst:]) < n {
-				if nDst == 0 {
-					err = transform.ErrShortDst
-				}
+				err = transform.ErrShortDst
 				return
 			}
 			m := copy(dst[nDst:], src[nSrc:nSrc+n])
@@ -128,9 +254,7 @@ func (r *Replacer) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err e
 		}
 
 		if len(dst[nDst:]) < i+len(r.new) {
-			if nDst == 0 {
-				err = transform.ErrShortDst
-			}
+			err = transform.ErrShortDst
 			return
 		}
 		nDst += copy(dst[nDst:], src[nSrc:nSrc+i])
@@ -140,6 +264,38 @@ func (r *Replacer) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err e
 	}
 }
 
+// WriteString replaces old to new in s and writes it to w, analogous to
+// strings.Replacer.WriteString.
+// WriteString is safe for concurrent use by multiple goroutines as long as
+// r.history is nil.
+func (r *Replacer) WriteString(w io.Writer, s string) (n int, err error) {
+	cw := &countingWriter{w: w}
+	tw := r.NewWriter(cw)
+	_, err = tw.Write([]byte(s))
+	if cerr := tw.Close(); err == nil {
+		err = cerr
+	}
+	return cw.n, err
+}
+
+// Replace returns a copy of s with every occurrence of old replaced by new,
+// analogous to strings.Replacer.Replace.
+// Replace is safe for concurrent use by multiple goroutines as long as
+// r.history is nil.
+func (r *Replacer) Replace(s []byte) []byte {
+	out, _, _ := transform.Bytes(r, s)
+	return out
+}
+
+// NewWriter wraps w so that everything written to it has old replaced by
+// new before reaching w. The returned Writer buffers boundary bytes between
+// Write calls, and Close flushes the tail as if atEOF were true.
+// NewWriter is safe for concurrent use by multiple goroutines as long as
+// r.history is nil.
+func (r *Replacer) NewWriter(w io.Writer) io.WriteCloser {
+	return transform.NewWriter(w, r)
+}
+
 // Replace returns a Replacer with out history.
 // It is a shorthand for NewReplacer(old, new, nil).
 func Replace(old, new []byte) *Replacer {
@@ -233,13 +389,10 @@ func (t ReplaceRuneTable) Len() int {
 	return len(t) / 2
 }
 
-// ReplaceAll creates transform.Transformer which is chained Replacers.
-// The Replacers replace by replacing rule which is indicated by ReplaceTable.
+// ReplaceAll creates transform.Transformer which replaces by the rules
+// indicated by ReplaceTable in a single pass over the source, so a rule can
+// never match text produced by another rule.
+// It is a shorthand for NewMultiReplacer(t, nil).
 func ReplaceAll(t ReplaceTable) transform.Transformer {
-	rs := make([]transform.Transformer, t.Len())
-	for i := range rs {
-		old, new := t.At(i)
-		rs[i] = Replace(old, new)
-	}
-	return transform.Chain(rs...)
+	return NewMultiReplacer(t, nil)
 }
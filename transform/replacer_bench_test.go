@@ -0,0 +1,28 @@
+package transform
+
+import (
+	"bytes"
+	"testing"
+)
+
+// BenchmarkReplacerLargeOld measures throughput of Replacer.Transform for a
+// long old pattern (32+ bytes), where NewReplacer builds a
+// Boyer-Moore-Horspool bad-character table.
+func BenchmarkReplacerLargeOld(b *testing.B) {
+	old := bytes.Repeat([]byte("abcdefghijklmnopqrstuvwxyz012345"), 1) // 32 bytes
+	new := []byte("x")
+
+	src := bytes.Repeat([]byte("a"), 1<<20)
+	src = append(src, old...)
+	src = append(src, bytes.Repeat([]byte("a"), 1<<20)...)
+
+	r := NewReplacer(old, new, nil)
+	dst := make([]byte, len(src))
+
+	b.SetBytes(int64(len(src)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Reset()
+		r.Transform(dst, src, true)
+	}
+}
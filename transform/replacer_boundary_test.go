@@ -0,0 +1,44 @@
+package transform
+
+import "testing"
+
+func TestReplacerReplaceShortTailAtEOF(t *testing.T) {
+	r := NewReplacer([]byte("aabaabaabaabaabaabaa"), []byte("R"), nil)
+
+	got := r.Replace([]byte("hello world"))
+	want := "hello world"
+	if string(got) != want {
+		t.Errorf("Replace() = %q, want %q", got, want)
+	}
+}
+
+func TestReplacerBoundaryOverlap(t *testing.T) {
+	// "old" is 4 bytes long so the Boyer-Moore-Horspool path is used. The
+	// first chunk ends with "aab", a 3-byte proper prefix of old whose
+	// length (3) is not congruent to len(src)%len(old) (5%4 == 1), which is
+	// exactly the case a naive modulo-based boundary check misses.
+	r := NewReplacer([]byte("aabc"), []byte("X"), nil)
+	dst := make([]byte, 64)
+
+	nDst, nSrc, err := r.Transform(dst, []byte("xxaab"), false)
+	if err == nil {
+		t.Fatalf("first Transform: got nil error, want ErrShortSrc")
+	}
+	if got, want := string(dst[:nDst]), "xx"; got != want {
+		t.Fatalf("first Transform flushed %q, want %q", got, want)
+	}
+	if nSrc != 2 {
+		t.Fatalf("first Transform consumed %d bytes, want 2 (holding back \"aab\")", nSrc)
+	}
+
+	// The caller must resend the held-back "aab" (src[nSrc:]) followed by
+	// whatever new data arrived.
+	rest := []byte("aabcxx")
+	nDst2, _, err := r.Transform(dst, rest, true)
+	if err != nil {
+		t.Fatalf("second Transform returned error: %v", err)
+	}
+	if got, want := string(dst[:nDst2]), "Xxx"; got != want {
+		t.Errorf("second Transform = %q, want %q", got, want)
+	}
+}
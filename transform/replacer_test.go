@@ -0,0 +1,202 @@
+package transform
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestReplacerReplace(t *testing.T) {
+	r := Replace([]byte("foo"), []byte("bar"))
+
+	got := r.Replace([]byte("foo baz foo"))
+	want := "bar baz bar"
+	if string(got) != want {
+		t.Errorf("Replace() = %q, want %q", got, want)
+	}
+}
+
+func TestReplacerWriteString(t *testing.T) {
+	r := Replace([]byte("foo"), []byte("bar"))
+
+	var buf bytes.Buffer
+	n, err := r.WriteString(&buf, "foo baz foo")
+	if err != nil {
+		t.Fatalf("WriteString returned error: %v", err)
+	}
+	if want := len("foo baz foo"); n != want {
+		t.Errorf("WriteString n = %d, want %d", n, want)
+	}
+	if want := "bar baz bar"; buf.String() != want {
+		t.Errorf("WriteString wrote %q, want %q", buf.String(), want)
+	}
+}
+
+func TestReplacerWriteStringReturnsOutputByteCount(t *testing.T) {
+	// "a" -> "bbbb" expands the input, so n must count bytes written to w
+	// (as strings.Replacer.WriteString does), not bytes consumed from s.
+	r := Replace([]byte("a"), []byte("bbbb"))
+
+	var buf bytes.Buffer
+	n, err := r.WriteString(&buf, "aa")
+	if err != nil {
+		t.Fatalf("WriteString returned error: %v", err)
+	}
+	if want := buf.Len(); n != want {
+		t.Errorf("WriteString n = %d, want %d (buf.Len())", n, want)
+	}
+	if want := "bbbbbbbb"; buf.String() != want {
+		t.Errorf("WriteString wrote %q, want %q", buf.String(), want)
+	}
+}
+
+func TestReplacerNewWriter(t *testing.T) {
+	r := Replace([]byte("foobar"), []byte("X"))
+
+	var buf bytes.Buffer
+	w := r.NewWriter(&buf)
+
+	// Split the pattern across two Write calls to exercise the boundary
+	// buffering NewWriter is responsible for.
+	if _, err := w.Write([]byte("foo")); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	if _, err := w.Write([]byte("bar baz")); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	if want := "X baz"; buf.String() != want {
+		t.Errorf("NewWriter round-trip = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestReplacerNewWriterExpandingPastBuffer(t *testing.T) {
+	r := Replace([]byte("a"), []byte("bb"))
+
+	var buf bytes.Buffer
+	w := r.NewWriter(&buf)
+
+	// transform.NewWriter's internal src/dst buffers are a fixed 4096 bytes
+	// that never grow, so an expanding rule applied to input well past that
+	// size forces dst to fill up mid-Transform; Write must keep retrying
+	// instead of returning early with a short write.
+	in := bytes.Repeat([]byte("a"), 10000)
+	if _, err := w.Write(in); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	want := bytes.Repeat([]byte("bb"), 10000)
+	if buf.Len() != len(want) {
+		t.Fatalf("NewWriter round-trip wrote %d bytes, want %d", buf.Len(), len(want))
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("NewWriter round-trip did not match expected output")
+	}
+}
+
+func TestMultiReplacerReplace(t *testing.T) {
+	var table ReplaceStringTable
+	table.Add("foo", "bar")
+	table.Add("bar", "baz")
+	r := NewMultiReplacer(table, nil)
+
+	got := r.Replace([]byte("foo bar"))
+	want := "bar baz"
+	if string(got) != want {
+		t.Errorf("Replace() = %q, want %q", got, want)
+	}
+}
+
+func TestMultiReplacerWriteStringReturnsOutputByteCount(t *testing.T) {
+	// "a" -> "bbbb" expands the input, so n must count bytes written to w
+	// (as strings.Replacer.WriteString does), not bytes consumed from s.
+	var table ReplaceStringTable
+	table.Add("a", "bbbb")
+	r := NewMultiReplacer(table, nil)
+
+	var buf bytes.Buffer
+	n, err := r.WriteString(&buf, "aa")
+	if err != nil {
+		t.Fatalf("WriteString returned error: %v", err)
+	}
+	if want := buf.Len(); n != want {
+		t.Errorf("WriteString n = %d, want %d (buf.Len())", n, want)
+	}
+	if want := "bbbbbbbb"; buf.String() != want {
+		t.Errorf("WriteString wrote %q, want %q", buf.String(), want)
+	}
+}
+
+func TestMultiReplacerNewWriter(t *testing.T) {
+	var table ReplaceStringTable
+	table.Add("foobar", "X")
+	r := NewMultiReplacer(table, nil)
+
+	var buf bytes.Buffer
+	w := r.NewWriter(&buf)
+
+	if _, err := w.Write([]byte("foo")); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	if _, err := w.Write([]byte("bar baz")); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	if want := "X baz"; buf.String() != want {
+		t.Errorf("NewWriter round-trip = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestMultiReplacerNewWriterExpandingPastBuffer(t *testing.T) {
+	var table ReplaceStringTable
+	table.Add("a", "bb")
+	r := NewMultiReplacer(table, nil)
+
+	var buf bytes.Buffer
+	w := r.NewWriter(&buf)
+
+	// Same concern as TestReplacerNewWriterExpandingPastBuffer: push input
+	// well past transform.NewWriter's fixed 4096-byte buffers so dst fills
+	// up mid-Transform at least once.
+	in := bytes.Repeat([]byte("a"), 10000)
+	if _, err := w.Write(in); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	want := bytes.Repeat([]byte("bb"), 10000)
+	if buf.Len() != len(want) {
+		t.Fatalf("NewWriter round-trip wrote %d bytes, want %d", buf.Len(), len(want))
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("NewWriter round-trip did not match expected output")
+	}
+}
+
+func TestMultiReplacerEOFTailRescan(t *testing.T) {
+	// At atEOF, "ab" wins the candidate window started for "abcd" once the
+	// end of src proves the longer pattern will not complete. The committed match must
+	// not swallow the remaining "c" raw: rescanning it still finds the
+	// standalone "c" -> "R" rule.
+	var table ReplaceStringTable
+	table.Add("abcd", "P")
+	table.Add("ab", "Q")
+	table.Add("c", "R")
+	r := NewMultiReplacer(table, nil)
+
+	got := r.Replace([]byte("abc"))
+	want := "QR"
+	if string(got) != want {
+		t.Errorf("Replace() = %q, want %q", got, want)
+	}
+}